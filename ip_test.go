@@ -0,0 +1,81 @@
+// log2life
+// by Brian C. Lane <bcl@brianlane.com>
+package main
+
+import (
+	"math/bits"
+	"testing"
+)
+
+// TestIPToXYIPv4RawMapping confirms -hash-ips=false reproduces the
+// original raw IPv4 mapping: upper 16 bits of the address scaled to width,
+// lower 16 bits scaled to height, both centered on 0,0.
+func TestIPToXYIPv4RawMapping(t *testing.T) {
+	x, y := IPToXY("0.0.255.255", 100, 100, false)
+	if x != -50 || y != 50 {
+		t.Fatalf("IPToXY raw = (%d, %d), want (-50, 50)", x, y)
+	}
+}
+
+// TestIPToXYIPv4InBounds confirms both the raw and mixed mappings stay
+// within the Life world regardless of address.
+func TestIPToXYIPv4InBounds(t *testing.T) {
+	for _, hashIPs := range []bool{false, true} {
+		for _, addr := range []string{"0.0.0.0", "255.255.255.255", "192.168.1.1", "10.0.0.254"} {
+			x, y := IPToXY(addr, 100, 100, hashIPs)
+			if x < -50 || x > 50 || y < -50 || y > 50 {
+				t.Fatalf("IPToXY(%s, hashIPs=%v) = (%d, %d), out of [-50, 50] bounds", addr, hashIPs, x, y)
+			}
+		}
+	}
+}
+
+// TestIPToXYIPv6InBounds confirms IPv6 addresses also land inside the Life
+// world, auto-detected via net.ParseIP.
+func TestIPToXYIPv6InBounds(t *testing.T) {
+	for _, addr := range []string{"::1", "2001:db8::1", "fe80::1234:5678:9abc:def0"} {
+		x, y := IPToXY(addr, 100, 100, true)
+		if x < -50 || x > 50 || y < -50 || y > 50 {
+			t.Fatalf("IPToXY(%s) = (%d, %d), out of [-50, 50] bounds", addr, x, y)
+		}
+	}
+}
+
+// TestIPToXYIPv6Mixing confirms two IPv6 addresses in the same /64 (which
+// would collapse to the same cell under a naive mapping) land on different
+// cells once mixed.
+func TestIPToXYIPv6Mixing(t *testing.T) {
+	x1, y1 := IPToXY("2001:db8::1", 1000, 1000, true)
+	x2, y2 := IPToXY("2001:db8::2", 1000, 1000, true)
+	if x1 == x2 && y1 == y2 {
+		t.Fatalf("neighboring IPv6 addresses collapsed to the same cell (%d, %d)", x1, y1)
+	}
+}
+
+// TestIPToXYInvalidAddress confirms an unparsable address returns 0, 0
+// rather than panicking.
+func TestIPToXYInvalidAddress(t *testing.T) {
+	x, y := IPToXY("not-an-ip", 100, 100, true)
+	if x != 0 || y != 0 {
+		t.Fatalf("IPToXY(invalid) = (%d, %d), want (0, 0)", x, y)
+	}
+}
+
+// TestMix32Avalanche confirms mix32 spreads a one-bit input change across
+// roughly half of the 32 output bits, averaged over a range of seeds.
+func TestMix32Avalanche(t *testing.T) {
+	var totalDiff, samples int
+	for seed := uint32(0); seed < 256; seed++ {
+		for bit := uint(0); bit < 32; bit++ {
+			a := mix32(seed)
+			b := mix32(seed ^ (1 << bit))
+			totalDiff += bits.OnesCount32(a ^ b)
+			samples++
+		}
+	}
+
+	avg := float64(totalDiff) / float64(samples)
+	if avg < 12 || avg > 20 {
+		t.Fatalf("average avalanche of %.2f bits/32 outside expected ~16 range", avg)
+	}
+}
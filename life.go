@@ -0,0 +1,156 @@
+// log2life
+// by Brian C. Lane <bcl@brianlane.com>
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PatternEncoder turns an 8x8 live-cell bitmap positioned at x,y into a
+// Life pattern, and reports the Content-Type it should be sent with.
+type PatternEncoder interface {
+	Encode(x, y int, data [8]byte) []string
+	ContentType() string
+}
+
+// newPatternEncoder returns the PatternEncoder named by format. It is
+// picked once at startup and reused for every line.
+func newPatternEncoder(format string) (PatternEncoder, error) {
+	switch format {
+	case "1.05":
+		return life105Encoder{}, nil
+	case "1.06":
+		return life106Encoder{}, nil
+	case "rle":
+		return rleEncoder{}, nil
+	default:
+		return nil, fmt.Errorf("unknown -pattern-format %q", format)
+	}
+}
+
+// life105Encoder emits Life 1.05, the original log2life format.
+type life105Encoder struct{}
+
+func (life105Encoder) ContentType() string { return "text/plain" }
+
+func (life105Encoder) Encode(x, y int, data [8]byte) []string {
+	return MakeLife105(x, y, data)
+}
+
+// life106Encoder emits Life 1.06, a more compact format for sparse patterns.
+type life106Encoder struct{}
+
+func (life106Encoder) ContentType() string { return "text/plain" }
+
+func (life106Encoder) Encode(x, y int, data [8]byte) []string {
+	return MakeLife106(x, y, data)
+}
+
+// rleEncoder emits run-length-encoded patterns.
+type rleEncoder struct{}
+
+func (rleEncoder) ContentType() string { return "text/plain" }
+
+func (rleEncoder) Encode(x, y int, data [8]byte) []string {
+	return MakeRLE(x, y, data)
+}
+
+// MakeLife105 converts an array of 8 bytes into a life 1.05 pattern string
+func MakeLife105(x, y int, data [8]byte) []string {
+	var pattern []string
+
+	pattern = append(pattern, "#Life 1.05")
+	pattern = append(pattern, "#D log2life ouput")
+	pattern = append(pattern, "#N")
+	pattern = append(pattern, fmt.Sprintf("#P %d %d", x, y))
+
+	for _, b := range data {
+		var line string
+		for i := 0; i < 8; i++ {
+			if b&0x80 == 0x80 {
+				line = line + "*"
+			} else {
+				line = line + "."
+			}
+
+			b = b << 1
+		}
+		pattern = append(pattern, line)
+	}
+
+	return pattern
+}
+
+// MakeLife106 converts an array of 8 bytes into a Life 1.06 pattern: a
+// header followed by one "x y" line per live cell. 1.06 has no #P-style
+// offset block, so x,y are folded directly into each cell's coordinates.
+func MakeLife106(x, y int, data [8]byte) []string {
+	pattern := []string{"#Life 1.06"}
+
+	for row, b := range data {
+		for col := 0; col < 8; col++ {
+			if b&(0x80>>uint(col)) != 0 {
+				pattern = append(pattern, fmt.Sprintf("%d %d", x+col, y+row))
+			}
+		}
+	}
+
+	return pattern
+}
+
+// MakeRLE converts an array of 8 bytes into a run-length encoded pattern:
+// a #P position comment (matching the convention used by MakeLife105), an
+// x/y/rule header, then the cells themselves as runs of 'b' (dead) and 'o'
+// (live) separated by '$' per row and terminated with '!'.
+func MakeRLE(x, y int, data [8]byte) []string {
+	pattern := []string{
+		fmt.Sprintf("#P %d %d", x, y),
+		"x=8,y=8,rule=B3/S23",
+	}
+
+	rows := make([]string, len(data))
+	for i, b := range data {
+		rows[i] = encodeRLERow(b)
+	}
+
+	pattern = append(pattern, strings.Join(rows, "$")+"!")
+	return pattern
+}
+
+// encodeRLERow run-length-encodes one row of 8 cells into RLE tokens,
+// dropping a trailing run of dead cells since '$' implicitly blanks the
+// rest of the row.
+func encodeRLERow(b byte) string {
+	var tokens strings.Builder
+	var runChar byte
+	var runLen int
+
+	flush := func() {
+		if runLen == 0 {
+			return
+		}
+		if runLen > 1 {
+			fmt.Fprintf(&tokens, "%d", runLen)
+		}
+		tokens.WriteByte(runChar)
+	}
+
+	for col := 0; col < 8; col++ {
+		c := byte('b')
+		if b&(0x80>>uint(col)) != 0 {
+			c = 'o'
+		}
+		if runLen > 0 && c != runChar {
+			flush()
+			runLen = 0
+		}
+		runChar = c
+		runLen++
+	}
+	if runChar == 'o' {
+		flush()
+	}
+
+	return tokens.String()
+}
@@ -0,0 +1,91 @@
+// log2life
+// by Brian C. Lane <bcl@brianlane.com>
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMakeLife106(t *testing.T) {
+	var data [8]byte
+	data[0] = 0x80 // top-left cell alive
+	data[7] = 0x01 // bottom-right cell alive
+
+	pattern := MakeLife106(10, 20, data)
+
+	if pattern[0] != "#Life 1.06" {
+		t.Fatalf("pattern[0] = %q, want header", pattern[0])
+	}
+	want := []string{"#Life 1.06", "10 20", "17 27"}
+	if len(pattern) != len(want) {
+		t.Fatalf("pattern = %v, want %v", pattern, want)
+	}
+	for i, line := range want {
+		if pattern[i] != line {
+			t.Fatalf("pattern[%d] = %q, want %q", i, pattern[i], line)
+		}
+	}
+}
+
+func TestMakeLife106Empty(t *testing.T) {
+	var data [8]byte
+	pattern := MakeLife106(0, 0, data)
+	if len(pattern) != 1 || pattern[0] != "#Life 1.06" {
+		t.Fatalf("pattern = %v, want just the header for an empty bitmap", pattern)
+	}
+}
+
+func TestMakeRLE(t *testing.T) {
+	var data [8]byte
+	data[0] = 0xe0 // "ooobbbbb" -> "3o" (trailing dead run dropped)
+
+	pattern := MakeRLE(3, 4, data)
+	want := []string{"#P 3 4", "x=8,y=8,rule=B3/S23"}
+	for i, line := range want {
+		if pattern[i] != line {
+			t.Fatalf("pattern[%d] = %q, want %q", i, pattern[i], line)
+		}
+	}
+
+	body := pattern[len(pattern)-1]
+	if !strings.HasSuffix(body, "!") {
+		t.Fatalf("body = %q, want a single trailing !", body)
+	}
+	if strings.Count(body, "!") != 1 {
+		t.Fatalf("body = %q, want exactly one !", body)
+	}
+	rows := strings.Split(strings.TrimSuffix(body, "!"), "$")
+	if len(rows) != 8 {
+		t.Fatalf("rows = %v, want 8 rows", rows)
+	}
+	if rows[0] != "3o" {
+		t.Fatalf("rows[0] = %q, want 3o", rows[0])
+	}
+	for i, row := range rows[1:] {
+		if row != "" {
+			t.Fatalf("rows[%d] = %q, want empty (all-dead rows drop their trailing run)", i+1, row)
+		}
+	}
+}
+
+func TestEncodeRLERow(t *testing.T) {
+	cases := []struct {
+		b    byte
+		want string
+	}{
+		{0x00, ""},
+		{0xff, "8o"},
+		{0x80, "o"},
+		{0xc0, "2o"},
+		{0x01, "7bo"},
+		{0xe2, "3o3bo"},
+	}
+
+	for _, c := range cases {
+		got := encodeRLERow(c.b)
+		if got != c.want {
+			t.Fatalf("encodeRLERow(%08b) = %q, want %q", c.b, got, c.want)
+		}
+	}
+}
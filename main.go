@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"math/bits"
 	"net"
 	"net/http"
 	"os"
@@ -17,12 +19,37 @@ import (
 
 /* commandline flags */
 type cmdlineArgs struct {
-	Logfile string  // Logfile to read
-	Speed   float64 // Playback speed factor 1.0 == realtime
-	Columns int     // Columns of Life world in cells
-	Rows    int     // Rows of Life world in cells
-	Port    int     // Port to connect to
-	Host    string  // Host IP to connect to
+	Logfile string        // Logfile to read
+	Speed   float64       // Playback speed factor 1.0 == realtime
+	Columns int           // Columns of Life world in cells
+	Rows    int           // Rows of Life world in cells
+	Port    int           // Port to connect to
+	Host    string        // Host IP to connect to
+	Follow  bool          // Tail the logfile like `tail -F`, handling rotation
+	Poll    time.Duration // Fallback poll interval used in follow mode
+	HashIPs bool          // Mix IP-derived coordinates instead of the raw mapping
+
+	Format           string // Log format: apache-common, apache-combined, nginx, syslog, json, or template
+	Template         string // User-defined pattern for -format template
+	JSONIPField      string // JSON field holding the client IP, for -format json
+	JSONTimeField    string // JSON field holding the timestamp, for -format json
+	JSONPayloadField string // JSON field holding the request payload, for -format json
+	JSONTimeFormat   string // time.Parse layout for the JSON timestamp field
+
+	Parser LogParser // Parser built from Format/Template once flags are parsed
+
+	Transport     string        // Transport to the life server: http or tcp
+	TLS           bool          // Use TLS for the tcp transport
+	CACert        string        // PEM CA certificate used to verify the server, for -tls
+	Cert          string        // PEM client certificate, for mutual TLS
+	Key           string        // PEM client key, for mutual TLS
+	BatchCount    int           // Coalesce this many patterns into one write (0 disables)
+	BatchInterval time.Duration // Coalesce patterns seen within this interval into one write (0 disables)
+
+	Sender Sender // Sender built from Transport/TLS once flags are parsed
+
+	PatternFormat string         // Output pattern format: 1.05, 1.06, or rle
+	Encoder       PatternEncoder // Encoder built from PatternFormat once flags are parsed
 }
 
 /* commandline defaults */
@@ -33,15 +60,47 @@ var cfg = cmdlineArgs{
 	Rows:    100,
 	Port:    3051,
 	Host:    "127.0.0.1",
+	Follow:  false,
+	Poll:    time.Second,
+	HashIPs: true,
+
+	Format:           "apache-common",
+	JSONIPField:      "remote_addr",
+	JSONTimeField:    "time",
+	JSONPayloadField: "request",
+	JSONTimeFormat:   time.RFC3339,
+
+	Transport: "http",
+
+	PatternFormat: "1.05",
 }
 
-/* parseArgs handles parsing the cmdline args and setting values in the global cfg struct */
-func init() {
+// parseArgs handles parsing the cmdline args and setting values in the global cfg struct.
+// It's called from main() rather than init() so that `go test` doesn't trip over flag.Parse()
+// seeing the test binary's own flags.
+func parseArgs() {
 	flag.Float64Var(&cfg.Speed, "speed", cfg.Speed, "Playback speed. 1.0 is realtime")
 	flag.IntVar(&cfg.Columns, "columns", cfg.Columns, "Width of Life world in cells")
 	flag.IntVar(&cfg.Rows, "rows", cfg.Rows, "Height of Life world in cells")
 	flag.IntVar(&cfg.Port, "port", cfg.Port, "Port to listen to")
 	flag.StringVar(&cfg.Host, "host", cfg.Host, "Host IP to bind to")
+	flag.BoolVar(&cfg.Follow, "follow", cfg.Follow, "Follow the logfile like tail -F, handling rotation")
+	flag.DurationVar(&cfg.Poll, "poll", cfg.Poll, "Fallback poll interval for -follow when fsnotify is quiet")
+	flag.BoolVar(&cfg.HashIPs, "hash-ips", cfg.HashIPs, "Mix IP-derived coordinates; disable for the legacy raw mapping")
+	flag.StringVar(&cfg.Format, "format", cfg.Format, "Log format: apache-common, apache-combined, nginx, syslog, json, template")
+	flag.StringVar(&cfg.Template, "template", cfg.Template, `Pattern for -format template, e.g. "${ip} ${ts:02/Jan/2006:15:04:05 -0700} ${payload}"`)
+	flag.StringVar(&cfg.JSONIPField, "json-ip-field", cfg.JSONIPField, "JSON field holding the client IP, for -format json")
+	flag.StringVar(&cfg.JSONTimeField, "json-time-field", cfg.JSONTimeField, "JSON field holding the timestamp, for -format json")
+	flag.StringVar(&cfg.JSONPayloadField, "json-payload-field", cfg.JSONPayloadField, "JSON field holding the request payload, for -format json")
+	flag.StringVar(&cfg.JSONTimeFormat, "json-time-format", cfg.JSONTimeFormat, "time.Parse layout for the JSON timestamp field")
+	flag.StringVar(&cfg.Transport, "transport", cfg.Transport, "Transport to the life server: http or tcp")
+	flag.BoolVar(&cfg.TLS, "tls", cfg.TLS, "Use TLS for the tcp transport")
+	flag.StringVar(&cfg.CACert, "cacert", cfg.CACert, "PEM CA certificate used to verify the server, for -tls")
+	flag.StringVar(&cfg.Cert, "cert", cfg.Cert, "PEM client certificate, for mutual TLS")
+	flag.StringVar(&cfg.Key, "key", cfg.Key, "PEM client key, for mutual TLS")
+	flag.IntVar(&cfg.BatchCount, "batch-count", cfg.BatchCount, "Coalesce this many patterns into one write (0 disables)")
+	flag.DurationVar(&cfg.BatchInterval, "batch-interval", cfg.BatchInterval, "Coalesce patterns seen within this interval into one write (0 disables)")
+	flag.StringVar(&cfg.PatternFormat, "pattern-format", cfg.PatternFormat, "Output pattern format: 1.05, 1.06, rle")
 
 	// first non flag argument is the logfile name
 	flag.Usage = func() {
@@ -50,15 +109,43 @@ func init() {
 	}
 
 	flag.Parse()
+
+	parser, err := newLogParser(cfg.Format, cfg.Template)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.Parser = parser
+
+	sender, err := newSender(cfg.Transport, cfg.Host, cfg.Port)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.Sender = sender
+
+	encoder, err := newPatternEncoder(cfg.PatternFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.Encoder = encoder
 }
 
 func main() {
+	parseArgs()
+
 	if flag.NArg() == 0 {
 		flag.Usage()
 		os.Exit(1)
 	}
 	filename := flag.Arg(0)
 
+	if cfg.Follow {
+		if filename == "-" {
+			log.Fatal("-follow cannot be used with <stdin>")
+		}
+		runFollow(filename)
+		return
+	}
+
 	var f *os.File
 	var err error
 	if filename == "-" {
@@ -76,6 +163,7 @@ func main() {
 		}
 		fmt.Printf("Playback of %s to %s:%d at %0.1fx speed\n", filename, cfg.Host, cfg.Port, cfg.Speed)
 	}
+	defer cfg.Sender.Close()
 
 	lastTime := time.Time{}
 	scanner := bufio.NewScanner(f)
@@ -98,7 +186,7 @@ func main() {
 		lastTime = timestamp
 
 		fmt.Printf("%s\n", strings.Join(pattern, "\n"))
-		err = SendPattern(cfg.Host, cfg.Port, pattern)
+		err = cfg.Sender.Send(pattern)
 		if err != nil {
 			fmt.Printf("ERROR: %s\n", err)
 		}
@@ -109,63 +197,152 @@ func main() {
 	}
 }
 
-// LineToPattern converts a log line to a Life 1.05 pattern with position based on the client IP
-func LineToPattern(line string, width, height int) ([]string, time.Time, error) {
+// runFollow tails filename like `tail -F`, reading new lines as they are
+// appended and transparently reopening the file across rotation, so a
+// long-running logfile keeps driving the Life world live. There are no
+// historical timestamps to catch up on, so the realtime delay used by the
+// non-follow playback loop is skipped.
+func runFollow(filename string) {
+	fmt.Printf("Following %s to %s:%d (poll %s)\n", filename, cfg.Host, cfg.Port, cfg.Poll)
+
+	t, err := newTailer(filename, cfg.Poll)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer t.Close()
+	defer cfg.Sender.Close()
+
+	for {
+		line, err := t.Next()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		pattern, _, err := LineToPattern(line, cfg.Columns, cfg.Rows)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
 
-	// Get the IP and convert to x, y coordinated, scaled by columns, rows and 0, 0 at the center
-	fields := strings.SplitN(line, " ", 4)
-	if fields[0] == "-" || strings.TrimSpace(fields[0]) == "" {
-		return []string{}, time.Time{}, fmt.Errorf("No client IP address")
+		fmt.Printf("%s\n", strings.Join(pattern, "\n"))
+		if err := cfg.Sender.Send(pattern); err != nil {
+			fmt.Printf("ERROR: %s\n", err)
+		}
 	}
-	x, y := IPToXY(fields[0], width, height)
+}
 
-	// Get the timestamp (will eventually return this and use it for timing)
-	// [20/Nov/2022:02:27:49 +0000]
-	fields = strings.SplitN(fields[3], "]", 2)
-	//	timestamp := fields[0][1:]
-	timestamp, err := time.Parse("02/Jan/2006:15:04:05 -0700", fields[0][1:])
+// LineToPattern converts a log line to a Life pattern, in the format of cfg.Encoder, with position based on the client IP.
+// It dispatches to cfg.Parser to pull the IP, timestamp, and payload out of the line, so this
+// stays the same regardless of which -format the line was written in.
+func LineToPattern(line string, width, height int) ([]string, time.Time, error) {
+	ip, timestamp, payload, err := cfg.Parser.Parse(line)
 	if err != nil {
 		return []string{}, time.Time{}, err
 	}
 
-	// XOR the data into an 8x8 bitpattern
-	// TODO Scramble this a bit more, all the log data is 7 bit
-	var data [8]byte
-	var idx int
-	for _, b := range []byte(fields[1]) {
-		// Skip quotes
+	// Convert the IP to x, y coordinates, scaled by columns, rows and 0, 0 at the center
+	x, y := IPToXY(ip, width, height, cfg.HashIPs)
+
+	// Mix the payload into an 8x8 bitpattern
+	data := hashPayload(payload)
+
+	// Convert the data to a pattern in the configured -pattern-format
+	return cfg.Encoder.Encode(x, y, data), timestamp, nil
+}
+
+// hashPayload mixes payload (skipping quote characters, since quoted log
+// fields shouldn't skew the result) into an 8-byte array using an
+// FNV-prime multiply-and-rotate accumulator. The old XOR-accumulate left
+// the high bit of every byte dead for 7-bit ASCII payloads; this doesn't.
+func hashPayload(payload []byte) [8]byte {
+	var state uint64
+	for _, b := range payload {
 		if b == byte('"') {
 			continue
 		}
-		data[idx] = data[idx] ^ b
-		idx = (idx + 1) % 8
+		state = bits.RotateLeft64(state^uint64(b)*0x100000001b3, 13)
 	}
 
-	// Convert the data to a Life 1.05 pattern
-	return MakeLife105(x, y, data), timestamp, nil
+	// splitmix64-style finalizer so a change late in a short payload still
+	// avalanches across the whole 64-bit state instead of only the bits
+	// touched by the last rotate.
+	state ^= state >> 30
+	state *= 0xbf58476d1ce4e5b9
+	state ^= state >> 27
+	state *= 0x94d049bb133111eb
+	state ^= state >> 31
+
+	var data [8]byte
+	for i := range data {
+		data[i] = byte(state >> (56 - 8*i))
+	}
+	return data
 }
 
-// IPToXY convert an IPv4 dotted quad into an X, Y coordinate
-func IPToXY(addr string, width, height int) (x, y int) {
+// IPToXY converts a client IPv4 or IPv6 address into an X, Y coordinate,
+// scaled to the life world size and with 0,0 at the center. The family is
+// auto-detected via net.ParseIP. Unless hashIPs is false, both halves of
+// the address are run through mix32 first so that neighboring addresses
+// (e.g. the same /24 or /64) don't collapse onto the same handful of
+// cells; hashIPs=false restores the legacy raw mapping for IPv4.
+func IPToXY(addr string, width, height int, hashIPs bool) (x, y int) {
 	ip := net.ParseIP(addr)
 	if ip == nil {
 		return 0, 0
 	}
 
-	// Only using IPv4 right now so 4 bytes from the ip which are at the end
-	// because it converts it to a IPv6 encoded IPv4
-	// Use the upper 16 bits as x and lower 16 as y, scaled to the life world size
-	// and with 0,0 at the center
-	x = int(float64(int(ip[12])<<8+int(ip[13]))/0xffff*float64(width)) - width/2
-	y = int(float64(int(ip[14])<<8+int(ip[15]))/0xffff*float64(height)) - height/2
+	if ip4 := ip.To4(); ip4 != nil {
+		// Use the upper 16 bits as x and lower 16 as y
+		xSeed := uint32(ip4[0])<<8 | uint32(ip4[1])
+		ySeed := uint32(ip4[2])<<8 | uint32(ip4[3])
+		if hashIPs {
+			xSeed = mix32(xSeed) & 0xffff
+			ySeed = mix32(ySeed) & 0xffff
+		}
+		x = int(float64(xSeed)/0xffff*float64(width)) - width/2
+		y = int(float64(ySeed)/0xffff*float64(height)) - height/2
+		return x, y
+	}
+
+	// IPv6: fold the 128-bit address into two 32-bit seeds by XOR-ing
+	// bytes 0..7 into the X seed and bytes 8..15 into the Y seed.
+	var xSeed, ySeed uint32
+	for i := 0; i < 8; i++ {
+		xSeed ^= uint32(ip[i]) << (8 * uint(i%4))
+	}
+	for i := 8; i < 16; i++ {
+		ySeed ^= uint32(ip[i]) << (8 * uint(i%4))
+	}
+	if hashIPs {
+		xSeed = mix32(xSeed)
+		ySeed = mix32(ySeed)
+	}
+	// Scale the same way the IPv4 raw path does (seed / max * width,
+	// centered on 0,0) rather than a modulo, so both families share one
+	// raw-mapping convention.
+	x = int(float64(xSeed)/float64(math.MaxUint32)*float64(width)) - width/2
+	y = int(float64(ySeed)/float64(math.MaxUint32)*float64(height)) - height/2
 
 	return x, y
 }
 
-// SendPattern POSTs a pattern to the life server and returns any errors
-func SendPattern(host string, port int, pattern []string) error {
+// mix32 is a splitmix/murmur-style finalizer used to scramble a 32-bit
+// seed derived from an IP address so that nearby inputs land on very
+// different cells.
+func mix32(x uint32) uint32 {
+	x ^= x >> 16
+	x *= 0x7feb352d
+	x ^= x >> 15
+	x *= 0x846ca68b
+	x ^= x >> 16
+	return x
+}
+
+// SendPattern POSTs a pattern to the life server with the given
+// Content-Type and returns any errors.
+func SendPattern(host string, port int, pattern []string, contentType string) error {
 	data := strings.NewReader(strings.Join(pattern, "\n"))
-	resp, err := http.Post(fmt.Sprintf("http://%s:%d", host, port), "text/plain", data)
+	resp, err := http.Post(fmt.Sprintf("http://%s:%d", host, port), contentType, data)
 	if err != nil {
 		return err
 	}
@@ -174,29 +351,3 @@ func SendPattern(host string, port int, pattern []string) error {
 	_, err = io.ReadAll(resp.Body)
 	return err
 }
-
-// MakeLife105 converts an array of 8 bytes into a life 1.05 pattern string
-func MakeLife105(x, y int, data [8]byte) []string {
-	var pattern []string
-
-	pattern = append(pattern, "#Life 1.05")
-	pattern = append(pattern, "#D log2life ouput")
-	pattern = append(pattern, "#N")
-	pattern = append(pattern, fmt.Sprintf("#P %d %d", x, y))
-
-	for _, b := range data {
-		var line string
-		for i := 0; i < 8; i++ {
-			if b&0x80 == 0x80 {
-				line = line + "*"
-			} else {
-				line = line + "."
-			}
-
-			b = b << 1
-		}
-		pattern = append(pattern, line)
-	}
-
-	return pattern
-}
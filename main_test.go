@@ -0,0 +1,52 @@
+// log2life
+// by Brian C. Lane <bcl@brianlane.com>
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// TestHashPayloadBitDensity checks that hashPayload produces roughly even
+// bit density across a corpus of payloads, confirming the high bit isn't
+// always dead the way the old XOR-accumulate left it for 7-bit ASCII.
+func TestHashPayloadBitDensity(t *testing.T) {
+	var ones, total int
+	for i := 0; i < 1000; i++ {
+		payload := []byte(fmt.Sprintf(`"GET /page/%d HTTP/1.1" 200 %d "-" "curl/%d"`, i, i*7, i))
+		data := hashPayload(payload)
+		for _, b := range data {
+			for bit := 0; bit < 8; bit++ {
+				total++
+				if b&(1<<uint(bit)) != 0 {
+					ones++
+				}
+			}
+		}
+	}
+
+	density := float64(ones) / float64(total)
+	if density < 0.45 || density > 0.55 {
+		t.Fatalf("bit density %.3f outside expected ~50%% range", density)
+	}
+}
+
+// TestHashPayloadAvalanche checks that two payloads differing by one byte
+// produce substantially different 8-byte outputs.
+func TestHashPayloadAvalanche(t *testing.T) {
+	a := hashPayload([]byte(`"GET /a HTTP/1.1" 200 100 "-" "curl/1"`))
+	b := hashPayload([]byte(`"GET /b HTTP/1.1" 200 100 "-" "curl/1"`))
+
+	diff := 0
+	for i := range a {
+		x := a[i] ^ b[i]
+		for x != 0 {
+			diff++
+			x &= x - 1
+		}
+	}
+
+	if diff < 16 {
+		t.Fatalf("expected avalanche effect, only %d/64 bits differ between outputs", diff)
+	}
+}
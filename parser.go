@@ -0,0 +1,278 @@
+// log2life
+// by Brian C. Lane <bcl@brianlane.com>
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// LogParser extracts the client IP, timestamp, and payload bytes (the part
+// of the line that gets hashed into the Life pattern) from one log line.
+// LineToPattern dispatches to cfg.Parser so the payload-hashing logic
+// stays the same no matter what log format is being read.
+type LogParser interface {
+	Parse(line string) (ip string, ts time.Time, payload []byte, err error)
+}
+
+// newLogParser builds the LogParser named by format. format "template"
+// compiles pattern (e.g. "${ip} ${ts:02/Jan/2006:15:04:05 -0700} ${payload}")
+// into a parser instead of using one of the named presets.
+func newLogParser(format, pattern string) (LogParser, error) {
+	switch format {
+	case "apache-common":
+		return apacheCommonParser{}, nil
+	case "apache-combined":
+		return apacheCombinedParser{}, nil
+	case "nginx":
+		return nginxParser{}, nil
+	case "syslog":
+		return syslogParser{}, nil
+	case "json":
+		return jsonParser{
+			ipField:      cfg.JSONIPField,
+			timeField:    cfg.JSONTimeField,
+			payloadField: cfg.JSONPayloadField,
+			timeFormat:   cfg.JSONTimeFormat,
+		}, nil
+	case "template":
+		return newTemplateParser(pattern)
+	default:
+		return nil, fmt.Errorf("unknown -format %q", format)
+	}
+}
+
+// apacheParseLine parses the common `IP ident authuser [timestamp] rest`
+// shape shared by Apache's common/combined logs and nginx's default log
+// format. The payload is everything after the timestamp, which covers the
+// combined layout too since it only appends referer/user-agent fields.
+func apacheParseLine(line string) (string, time.Time, []byte, error) {
+	fields := strings.SplitN(line, " ", 4)
+	if len(fields) < 4 || fields[0] == "-" || strings.TrimSpace(fields[0]) == "" {
+		return "", time.Time{}, nil, fmt.Errorf("No client IP address")
+	}
+
+	rest := strings.SplitN(fields[3], "]", 2)
+	if len(rest) < 2 || len(rest[0]) < 1 {
+		return "", time.Time{}, nil, fmt.Errorf("No timestamp found")
+	}
+	ts, err := time.Parse("02/Jan/2006:15:04:05 -0700", rest[0][1:])
+	if err != nil {
+		return "", time.Time{}, nil, err
+	}
+
+	return fields[0], ts, []byte(rest[1]), nil
+}
+
+// apacheCommonParser parses Apache's "common" log format.
+type apacheCommonParser struct{}
+
+func (apacheCommonParser) Parse(line string) (string, time.Time, []byte, error) {
+	return apacheParseLine(line)
+}
+
+// apacheCombinedParser parses Apache's "combined" log format (common plus
+// quoted referer and user-agent fields).
+type apacheCombinedParser struct{}
+
+func (apacheCombinedParser) Parse(line string) (string, time.Time, []byte, error) {
+	return apacheParseLine(line)
+}
+
+// nginxParser parses nginx's default access log format, which follows the
+// same IP/timestamp-in-brackets/rest shape as Apache's combined format.
+type nginxParser struct{}
+
+func (nginxParser) Parse(line string) (string, time.Time, []byte, error) {
+	return apacheParseLine(line)
+}
+
+// syslogParser handles both RFC3164 (BSD) and RFC5424 syslog framing.
+// Neither has a dedicated client-IP field, so the first token in the
+// message body that parses as an IP address is used.
+type syslogParser struct{}
+
+func (syslogParser) Parse(line string) (string, time.Time, []byte, error) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "<") {
+		if end := strings.Index(line, ">"); end != -1 {
+			line = line[end+1:]
+		}
+	}
+
+	var ts time.Time
+	var rest string
+	if fields := strings.SplitN(line, " ", 2); len(fields) == 2 && fields[0] == "1" {
+		// RFC5424: VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID ... MSG
+		parts := strings.SplitN(fields[1], " ", 2)
+		t, err := time.Parse(time.RFC3339, parts[0])
+		if err != nil {
+			return "", time.Time{}, nil, err
+		}
+		ts = t
+		if len(parts) == 2 {
+			rest = parts[1]
+		}
+	} else {
+		// RFC3164: "Jan _2 15:04:05 HOSTNAME TAG: MSG" (no year in the timestamp)
+		if len(line) < 15 {
+			return "", time.Time{}, nil, fmt.Errorf("line too short for a syslog timestamp")
+		}
+		parsed, err := time.Parse("Jan _2 15:04:05", line[:15])
+		if err != nil {
+			return "", time.Time{}, nil, err
+		}
+		ts = time.Date(time.Now().Year(), parsed.Month(), parsed.Day(),
+			parsed.Hour(), parsed.Minute(), parsed.Second(), 0, time.Local)
+		rest = strings.TrimSpace(line[15:])
+	}
+
+	ip := firstIP(rest)
+	if ip == "" {
+		return "", time.Time{}, nil, fmt.Errorf("No client IP address")
+	}
+
+	return ip, ts, []byte(rest), nil
+}
+
+// firstIP returns the first whitespace-delimited token in s that parses as
+// an IP address, or "" if none is found.
+func firstIP(s string) string {
+	for _, tok := range strings.Fields(s) {
+		tok = strings.Trim(tok, "[]():,")
+		if net.ParseIP(tok) != nil {
+			return tok
+		}
+	}
+	return ""
+}
+
+// jsonParser decodes JSON-lines logs, reading the IP, timestamp, and
+// payload from configurable field names.
+type jsonParser struct {
+	ipField      string
+	timeField    string
+	payloadField string
+	timeFormat   string
+}
+
+func (p jsonParser) Parse(line string) (string, time.Time, []byte, error) {
+	var rec map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &rec); err != nil {
+		return "", time.Time{}, nil, err
+	}
+
+	ip, _ := rec[p.ipField].(string)
+	if ip == "" {
+		return "", time.Time{}, nil, fmt.Errorf("No client IP address")
+	}
+
+	tsStr, _ := rec[p.timeField].(string)
+	ts, err := time.Parse(p.timeFormat, tsStr)
+	if err != nil {
+		return "", time.Time{}, nil, err
+	}
+
+	var payload []byte
+	switch v := rec[p.payloadField].(type) {
+	case string:
+		payload = []byte(v)
+	case nil:
+		payload = nil
+	default:
+		payload, _ = json.Marshal(v)
+	}
+
+	return ip, ts, payload, nil
+}
+
+// templateFieldRe matches the ${ip}, ${payload}, and ${ts} or
+// ${ts:layout} placeholders in a user-supplied template pattern.
+var templateFieldRe = regexp.MustCompile(`\$\{(ip|payload|ts(?::([^}]*))?)\}`)
+
+// templateParser parses lines matching a user-defined template such as
+// `${ip} ${ts:02/Jan/2006:15:04:05 -0700} ${payload}`, for logs that don't
+// fit any of the named presets.
+type templateParser struct {
+	re       *regexp.Regexp
+	tsLayout string
+	groups   []string // "ip", "ts", or "payload", one per capture group in re
+}
+
+// newTemplateParser compiles pattern into a templateParser by turning each
+// ${...} placeholder into a capture group and escaping the literal text
+// in between.
+func newTemplateParser(pattern string) (*templateParser, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("-format template requires a -template pattern")
+	}
+
+	var reStr strings.Builder
+	var groups []string
+	tsLayout := "02/Jan/2006:15:04:05 -0700"
+
+	last := 0
+	for _, m := range templateFieldRe.FindAllStringSubmatchIndex(pattern, -1) {
+		reStr.WriteString(regexp.QuoteMeta(pattern[last:m[0]]))
+		field := pattern[m[2]:m[3]]
+		switch {
+		case field == "ip":
+			groups = append(groups, "ip")
+			reStr.WriteString(`(\S+)`)
+		case field == "payload":
+			groups = append(groups, "payload")
+			reStr.WriteString(`(.*)`)
+		case strings.HasPrefix(field, "ts"):
+			groups = append(groups, "ts")
+			if m[4] != -1 {
+				tsLayout = pattern[m[4]:m[5]]
+			}
+			reStr.WriteString(`(.+?)`)
+		}
+		last = m[1]
+	}
+	reStr.WriteString(regexp.QuoteMeta(pattern[last:]))
+
+	re, err := regexp.Compile("^" + reStr.String() + "$")
+	if err != nil {
+		return nil, err
+	}
+
+	return &templateParser{re: re, tsLayout: tsLayout, groups: groups}, nil
+}
+
+func (p *templateParser) Parse(line string) (string, time.Time, []byte, error) {
+	m := p.re.FindStringSubmatch(line)
+	if m == nil {
+		return "", time.Time{}, nil, fmt.Errorf("line does not match -template pattern")
+	}
+
+	var ip string
+	var ts time.Time
+	var payload []byte
+	for i, g := range p.groups {
+		val := m[i+1]
+		switch g {
+		case "ip":
+			ip = val
+		case "ts":
+			t, err := time.Parse(p.tsLayout, val)
+			if err != nil {
+				return "", time.Time{}, nil, err
+			}
+			ts = t
+		case "payload":
+			payload = []byte(val)
+		}
+	}
+
+	if ip == "" {
+		return "", time.Time{}, nil, fmt.Errorf("No client IP address")
+	}
+
+	return ip, ts, payload, nil
+}
@@ -0,0 +1,164 @@
+// log2life
+// by Brian C. Lane <bcl@brianlane.com>
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApacheCommonParser(t *testing.T) {
+	line := `192.168.1.1 - - [20/Nov/2022:02:27:49 +0000] "GET / HTTP/1.1" 200 612`
+	ip, ts, payload, err := apacheCommonParser{}.Parse(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "192.168.1.1" {
+		t.Fatalf("ip = %q, want 192.168.1.1", ip)
+	}
+	want := time.Date(2022, time.November, 20, 2, 27, 49, 0, time.UTC)
+	if !ts.Equal(want) {
+		t.Fatalf("ts = %v, want %v", ts, want)
+	}
+	if string(payload) != ` "GET / HTTP/1.1" 200 612` {
+		t.Fatalf("payload = %q", payload)
+	}
+}
+
+func TestApacheCombinedParser(t *testing.T) {
+	line := `192.168.1.1 - - [20/Nov/2022:02:27:49 +0000] "GET / HTTP/1.1" 200 612 "-" "curl/7.81.0"`
+	ip, _, payload, err := apacheCombinedParser{}.Parse(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "192.168.1.1" {
+		t.Fatalf("ip = %q, want 192.168.1.1", ip)
+	}
+	if string(payload) != ` "GET / HTTP/1.1" 200 612 "-" "curl/7.81.0"` {
+		t.Fatalf("payload = %q", payload)
+	}
+}
+
+func TestApacheParseLineNoIP(t *testing.T) {
+	if _, _, _, err := (apacheCommonParser{}).Parse(`- - - [20/Nov/2022:02:27:49 +0000] "GET / HTTP/1.1" 200 612`); err == nil {
+		t.Fatal("expected an error for a missing client IP")
+	}
+}
+
+func TestNginxParser(t *testing.T) {
+	line := `10.0.0.5 - - [20/Nov/2022:02:27:49 +0000] "GET /health HTTP/1.1" 200 2`
+	ip, _, _, err := nginxParser{}.Parse(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "10.0.0.5" {
+		t.Fatalf("ip = %q, want 10.0.0.5", ip)
+	}
+}
+
+func TestSyslogParserRFC3164(t *testing.T) {
+	line := `Nov 20 02:27:49 webhost app[123]: request from 10.0.0.5 succeeded`
+	ip, ts, payload, err := syslogParser{}.Parse(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "10.0.0.5" {
+		t.Fatalf("ip = %q, want 10.0.0.5", ip)
+	}
+	if ts.Month() != time.November || ts.Day() != 20 || ts.Hour() != 2 {
+		t.Fatalf("ts = %v, want Nov 20 02:27:49", ts)
+	}
+	if string(payload) != "webhost app[123]: request from 10.0.0.5 succeeded" {
+		t.Fatalf("payload = %q", payload)
+	}
+}
+
+func TestSyslogParserRFC5424(t *testing.T) {
+	line := `1 2022-11-20T02:27:49Z webhost app 123 - - request from 10.0.0.5 succeeded`
+	ip, ts, _, err := syslogParser{}.Parse(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "10.0.0.5" {
+		t.Fatalf("ip = %q, want 10.0.0.5", ip)
+	}
+	want := time.Date(2022, time.November, 20, 2, 27, 49, 0, time.UTC)
+	if !ts.Equal(want) {
+		t.Fatalf("ts = %v, want %v", ts, want)
+	}
+}
+
+func TestSyslogParserNoIP(t *testing.T) {
+	if _, _, _, err := (syslogParser{}).Parse(`Nov 20 02:27:49 webhost app[123]: no address here`); err == nil {
+		t.Fatal("expected an error when no IP address is present")
+	}
+}
+
+func TestJSONParser(t *testing.T) {
+	p := jsonParser{
+		ipField:      "remote_addr",
+		timeField:    "time",
+		payloadField: "request",
+		timeFormat:   time.RFC3339,
+	}
+	line := `{"remote_addr":"10.0.0.5","time":"2022-11-20T02:27:49Z","request":"GET / HTTP/1.1"}`
+	ip, ts, payload, err := p.Parse(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "10.0.0.5" {
+		t.Fatalf("ip = %q, want 10.0.0.5", ip)
+	}
+	want := time.Date(2022, time.November, 20, 2, 27, 49, 0, time.UTC)
+	if !ts.Equal(want) {
+		t.Fatalf("ts = %v, want %v", ts, want)
+	}
+	if string(payload) != "GET / HTTP/1.1" {
+		t.Fatalf("payload = %q", payload)
+	}
+}
+
+func TestJSONParserMissingIP(t *testing.T) {
+	p := jsonParser{ipField: "remote_addr", timeField: "time", payloadField: "request", timeFormat: time.RFC3339}
+	if _, _, _, err := p.Parse(`{"time":"2022-11-20T02:27:49Z","request":"GET / HTTP/1.1"}`); err == nil {
+		t.Fatal("expected an error for a missing IP field")
+	}
+}
+
+func TestTemplateParser(t *testing.T) {
+	p, err := newTemplateParser(`${ip} ${ts:2006-01-02T15:04:05Z} ${payload}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ip, ts, payload, err := p.Parse(`192.168.1.1 2022-11-20T02:27:49Z GET / HTTP/1.1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "192.168.1.1" {
+		t.Fatalf("ip = %q, want 192.168.1.1", ip)
+	}
+	want := time.Date(2022, time.November, 20, 2, 27, 49, 0, time.UTC)
+	if !ts.Equal(want) {
+		t.Fatalf("ts = %v, want %v", ts, want)
+	}
+	if string(payload) != "GET / HTTP/1.1" {
+		t.Fatalf("payload = %q", payload)
+	}
+}
+
+func TestTemplateParserNoMatch(t *testing.T) {
+	p, err := newTemplateParser(`${ip} ${payload}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := p.Parse(""); err == nil {
+		t.Fatal("expected an error for a line that doesn't match the template")
+	}
+}
+
+func TestNewLogParserUnknownFormat(t *testing.T) {
+	if _, err := newLogParser("bogus", ""); err == nil {
+		t.Fatal("expected an error for an unknown -format")
+	}
+}
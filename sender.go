@@ -0,0 +1,249 @@
+// log2life
+// by Brian C. Lane <bcl@brianlane.com>
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// frameMagic identifies a log2life TCP frame.
+var frameMagic = [4]byte{'L', '2', 'L', 0x01}
+
+const frameVersion = 1
+
+// Sender delivers a Life pattern to the life server. HTTPSender POSTs one
+// pattern per request, matching the original behavior; TCPSender writes
+// framed patterns over a single persistent connection.
+type Sender interface {
+	Send(pattern []string) error
+	Close() error
+}
+
+// newSender builds the Sender configured by -transport, optionally
+// wrapped in a batchSender if -batch-count or -batch-interval is set.
+func newSender(transport, host string, port int) (Sender, error) {
+	var s Sender
+	var err error
+
+	switch transport {
+	case "http":
+		s = &HTTPSender{host: host, port: port}
+	case "tcp":
+		s, err = newTCPSender(host, port)
+	default:
+		return nil, fmt.Errorf("unknown -transport %q", transport)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.BatchCount > 0 || cfg.BatchInterval > 0 {
+		// Only 1.05 supports concatenating several patterns into one file
+		// (it repeats the #P block per pattern); 1.06 and rle each carry a
+		// single header/terminator, so gluing two of them together
+		// corrupts both.
+		if cfg.PatternFormat != "1.05" {
+			log.Printf("-batch-count/-batch-interval require -pattern-format 1.05; ignoring for %s", cfg.PatternFormat)
+		} else {
+			s = newBatchSender(s, cfg.BatchCount, cfg.BatchInterval)
+		}
+	}
+
+	return s, nil
+}
+
+// HTTPSender POSTs one pattern per request.
+type HTTPSender struct {
+	host string
+	port int
+}
+
+// Send POSTs pattern to the life server using the configured encoder's
+// Content-Type.
+func (s *HTTPSender) Send(pattern []string) error {
+	return SendPattern(s.host, s.port, pattern, cfg.Encoder.ContentType())
+}
+
+// Close is a no-op for HTTPSender since it holds no connection of its
+// own; SendPattern goes through http.Post's shared default client.
+func (s *HTTPSender) Close() error { return nil }
+
+// TCPSender writes length-prefixed frames over a single persistent TCP
+// (optionally TLS) connection, so high log rates don't pay for a new
+// connection on every pattern.
+type TCPSender struct {
+	conn net.Conn
+}
+
+// newTCPSender dials host:port, using TLS (and optional mutual TLS) when
+// -tls is set.
+func newTCPSender(host string, port int) (*TCPSender, error) {
+	addr := fmt.Sprintf("%s:%d", host, port)
+
+	var conn net.Conn
+	var err error
+	if cfg.TLS {
+		var tlsConfig *tls.Config
+		tlsConfig, err = buildTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		conn, err = tls.Dial("tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &TCPSender{conn: conn}, nil
+}
+
+// Send writes pattern as a single length-prefixed frame.
+func (s *TCPSender) Send(pattern []string) error {
+	return writeFrame(s.conn, []byte(strings.Join(pattern, "\n")))
+}
+
+// Close closes the underlying TCP connection.
+func (s *TCPSender) Close() error {
+	return s.conn.Close()
+}
+
+// writeFrame writes one frame to w: a 4-byte magic, a 1-byte version, a
+// 4-byte big-endian payload length, then the payload itself.
+func writeFrame(w net.Conn, payload []byte) error {
+	var header bytes.Buffer
+	header.Write(frameMagic[:])
+	header.WriteByte(frameVersion)
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(payload)))
+	header.Write(length[:])
+
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// buildTLSConfig assembles a tls.Config from -cacert/-cert/-key for
+// optional mutual TLS.
+func buildTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if cfg.CACert != "" {
+		pem, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("failed to parse CA certificate %s", cfg.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.Cert != "" || cfg.Key != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// batchSender coalesces up to count patterns, or however many arrive
+// within interval (whichever comes first), into one write to the wrapped
+// Sender to amortize syscall overhead under high log rates.
+type batchSender struct {
+	next     Sender
+	count    int
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending [][]string
+	timer   *time.Timer
+}
+
+// newBatchSender wraps next so that Send batches patterns instead of
+// writing each one immediately. A count or interval of 0 disables that
+// trigger.
+func newBatchSender(next Sender, count int, interval time.Duration) *batchSender {
+	return &batchSender{next: next, count: count, interval: interval}
+}
+
+// Send queues pattern and flushes the batch once count patterns have
+// accumulated; otherwise it arms the interval timer (if not already
+// running) to flush later.
+func (b *batchSender) Send(pattern []string) error {
+	b.mu.Lock()
+	b.pending = append(b.pending, pattern)
+
+	full := b.count > 0 && len(b.pending) >= b.count
+	if !full {
+		if b.timer == nil && b.interval > 0 {
+			b.timer = time.AfterFunc(b.interval, b.flushTimer)
+		}
+		b.mu.Unlock()
+		return nil
+	}
+
+	err := b.flushLocked()
+	b.mu.Unlock()
+	return err
+}
+
+// flushTimer is invoked by the interval timer to flush whatever has
+// accumulated since the last flush.
+func (b *batchSender) flushTimer() {
+	b.mu.Lock()
+	err := b.flushLocked()
+	b.mu.Unlock()
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err)
+	}
+}
+
+// flushLocked writes all pending patterns as one combined write. Callers
+// must hold b.mu.
+func (b *batchSender) flushLocked() error {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.pending) == 0 {
+		return nil
+	}
+
+	var combined []string
+	for _, p := range b.pending {
+		combined = append(combined, p...)
+	}
+	b.pending = nil
+
+	return b.next.Send(combined)
+}
+
+// Close flushes any pending patterns and closes the wrapped Sender.
+func (b *batchSender) Close() error {
+	b.mu.Lock()
+	err := b.flushLocked()
+	b.mu.Unlock()
+
+	if cerr := b.next.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
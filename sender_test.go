@@ -0,0 +1,206 @@
+// log2life
+// by Brian C. Lane <bcl@brianlane.com>
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// readFrame reads one length-prefixed frame written by writeFrame, returning
+// its payload.
+func readFrame(t *testing.T, r io.Reader) []byte {
+	t.Helper()
+
+	header := make([]byte, 9)
+	if _, err := io.ReadFull(r, header); err != nil {
+		t.Fatalf("reading frame header: %v", err)
+	}
+	if !bytes.Equal(header[:4], frameMagic[:]) {
+		t.Fatalf("frame magic = %v, want %v", header[:4], frameMagic[:])
+	}
+	if header[4] != frameVersion {
+		t.Fatalf("frame version = %d, want %d", header[4], frameVersion)
+	}
+
+	length := binary.BigEndian.Uint32(header[5:9])
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatalf("reading frame payload: %v", err)
+	}
+	return payload
+}
+
+// TestTCPSenderSendWritesFramedPattern confirms TCPSender.Send writes one
+// frame over a loopback connection with the magic/version/length header
+// writeFrame defines, followed by the pattern joined with newlines.
+func TestTCPSenderSendWritesFramedPattern(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	acceptCh := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		acceptCh <- conn
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	clientConn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clientConn.Close()
+
+	serverConn := <-acceptCh
+	defer serverConn.Close()
+
+	s := &TCPSender{conn: clientConn}
+	pattern := []string{"#Life 1.05", "#P 0 0", "*......."}
+	if err := s.Send(pattern); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := readFrame(t, serverConn)
+	want := "#Life 1.05\n#P 0 0\n*......."
+	if string(payload) != want {
+		t.Fatalf("frame payload = %q, want %q", payload, want)
+	}
+}
+
+// fakeSender records every pattern passed to Send so batchSender's
+// coalescing behavior can be inspected directly. Send is called from the
+// interval timer's own goroutine as well as the test goroutine, so sends is
+// guarded by a mutex rather than read unsynchronized.
+type fakeSender struct {
+	mu    sync.Mutex
+	sends [][]string
+}
+
+func (f *fakeSender) Send(pattern []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sends = append(f.sends, pattern)
+	return nil
+}
+
+func (f *fakeSender) Close() error { return nil }
+
+// snapshot returns a copy of the sends recorded so far.
+func (f *fakeSender) snapshot() [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([][]string(nil), f.sends...)
+}
+
+// TestBatchSenderFlushesOnCount confirms batchSender coalesces exactly
+// count patterns into a single combined write to the wrapped Sender.
+func TestBatchSenderFlushesOnCount(t *testing.T) {
+	fake := &fakeSender{}
+	b := newBatchSender(fake, 2, 0)
+
+	if err := b.Send([]string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+	if sends := fake.snapshot(); len(sends) != 0 {
+		t.Fatalf("flushed after 1 of 2 patterns: %v", sends)
+	}
+
+	if err := b.Send([]string{"b"}); err != nil {
+		t.Fatal(err)
+	}
+	sends := fake.snapshot()
+	if len(sends) != 1 {
+		t.Fatalf("sends = %v, want exactly one combined write", sends)
+	}
+	want := []string{"a", "b"}
+	if !equalStringSlices(sends[0], want) {
+		t.Fatalf("combined write = %v, want %v", sends[0], want)
+	}
+}
+
+// TestBatchSenderFlushesOnInterval confirms batchSender flushes whatever
+// has accumulated once the interval elapses, even if count hasn't been hit.
+func TestBatchSenderFlushesOnInterval(t *testing.T) {
+	fake := &fakeSender{}
+	b := newBatchSender(fake, 10, 20*time.Millisecond)
+
+	if err := b.Send([]string{"a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.After(2 * time.Second)
+	var sends [][]string
+	for {
+		if sends = fake.snapshot(); len(sends) != 0 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for the interval flush")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+
+	want := []string{"a"}
+	if !equalStringSlices(sends[0], want) {
+		t.Fatalf("combined write = %v, want %v", sends[0], want)
+	}
+}
+
+func equalStringSlices(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestNewSenderIgnoresBatchingForNonLife105 confirms that -batch-count and
+// -batch-interval are ignored (rather than silently corrupting output) for
+// pattern formats that don't support concatenating several patterns into
+// one file.
+func TestNewSenderIgnoresBatchingForNonLife105(t *testing.T) {
+	savedFormat, savedCount, savedInterval := cfg.PatternFormat, cfg.BatchCount, cfg.BatchInterval
+	defer func() {
+		cfg.PatternFormat, cfg.BatchCount, cfg.BatchInterval = savedFormat, savedCount, savedInterval
+	}()
+
+	for _, format := range []string{"1.06", "rle"} {
+		cfg.PatternFormat = format
+		cfg.BatchCount = 2
+		cfg.BatchInterval = 0
+
+		s, err := newSender("http", "127.0.0.1", 3051)
+		if err != nil {
+			t.Fatalf("newSender(%s): %v", format, err)
+		}
+		if _, batched := s.(*batchSender); batched {
+			t.Fatalf("newSender(%s) wrapped with batchSender, want batching ignored", format)
+		}
+	}
+
+	cfg.PatternFormat = "1.05"
+	cfg.BatchCount = 2
+	s, err := newSender("http", "127.0.0.1", 3051)
+	if err != nil {
+		t.Fatalf("newSender(1.05): %v", err)
+	}
+	if _, batched := s.(*batchSender); !batched {
+		t.Fatalf("newSender(1.05) not wrapped with batchSender, want batching enabled")
+	}
+}
@@ -0,0 +1,178 @@
+// log2life
+// by Brian C. Lane <bcl@brianlane.com>
+package main
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// tailer follows a log file the way `tail -F` does: it keeps reading new
+// lines as they are appended, and reopens the path when the underlying
+// file is rotated, truncated, or renamed out from under it.
+type tailer struct {
+	path   string
+	poll   time.Duration
+	file   *os.File
+	reader *bufio.Reader
+
+	watcher *fsnotify.Watcher
+	ino     uint64
+	size    int64
+
+	pending strings.Builder // bytes read so far for a line still missing its newline
+}
+
+// newTailer opens path for follow mode and watches its parent directory so
+// rotation events can be noticed as soon as they happen, falling back to
+// polling every poll interval if fsnotify has nothing to report.
+func newTailer(path string, poll time.Duration) (*tailer, error) {
+	t := &tailer{path: path, poll: poll}
+	if err := t.open(); err != nil {
+		return nil, err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.file.Close()
+		return nil, err
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		t.file.Close()
+		return nil, err
+	}
+	t.watcher = watcher
+
+	return t, nil
+}
+
+// open (re)opens t.path and records its inode so future calls can detect
+// rotation or rename. t.size tracks bytes consumed from this file via
+// t.reader, so it starts at 0: reading begins at offset 0 on every open.
+func (t *tailer) open() error {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	t.file = f
+	t.reader = bufio.NewReader(f)
+	t.ino = inode(fi)
+	t.size = 0
+
+	return nil
+}
+
+// reopen closes the current file and opens the path fresh from the start,
+// logging the rotation so it is visible in the playback output.
+func (t *tailer) reopen() error {
+	if t.file != nil {
+		t.file.Close()
+	}
+	log.Printf("log rotation detected on %s, reopening", t.path)
+	return t.open()
+}
+
+// rotated reports whether t.path now refers to a different file than the
+// one currently open: a changed inode (rotate/rename) or a size smaller
+// than what has already been read (truncation).
+func (t *tailer) rotated() bool {
+	fi, err := os.Stat(t.path)
+	if err != nil {
+		// Path is missing, likely mid-rename. Report rotated so the
+		// caller keeps retrying until the new file shows up.
+		return true
+	}
+	if inode(fi) != t.ino {
+		return true
+	}
+	return fi.Size() < t.size
+}
+
+// wait blocks until the watcher reports activity near t.path or the poll
+// interval elapses, whichever comes first. The watcher's Errors channel is
+// drained here too: fsnotify's event-reader goroutine sends both Events and
+// Errors from the same loop, so an unread error would block that goroutine
+// forever and silently degrade follow mode to poll-only.
+func (t *tailer) wait() {
+	select {
+	case <-t.watcher.Events:
+	case err := <-t.watcher.Errors:
+		log.Printf("watcher error on %s: %v", t.path, err)
+	case <-time.After(t.poll):
+	}
+}
+
+// Next blocks until a new line is available, transparently reopening the
+// file if rotation is detected, and returns the line without its trailing
+// newline. A line written across more than one poll (ReadString hits EOF
+// before the writer's newline lands) is accumulated in t.pending instead
+// of being handed up as a truncated fragment.
+func (t *tailer) Next() (string, error) {
+	for {
+		chunk, err := t.reader.ReadString('\n')
+		t.size += int64(len(chunk))
+		t.pending.WriteString(chunk)
+
+		if err == nil {
+			line := t.pending.String()
+			t.pending.Reset()
+			return strings.TrimRight(line, "\r\n"), nil
+		}
+		if err != io.EOF {
+			return "", err
+		}
+
+		if t.rotated() {
+			if _, err := os.Stat(t.path); os.IsNotExist(err) {
+				// Path is gone mid-rename: keep the old file open and
+				// retry until the new one shows up.
+				t.wait()
+				continue
+			}
+			// The file changed out from under the partial line; it will
+			// never get its newline, so drop it rather than splicing it
+			// onto whatever the new file starts with.
+			t.pending.Reset()
+			if err := t.reopen(); err != nil {
+				return "", err
+			}
+			continue
+		}
+		t.wait()
+	}
+}
+
+// Close releases the watcher and the underlying file.
+func (t *tailer) Close() error {
+	if t.watcher != nil {
+		t.watcher.Close()
+	}
+	if t.file != nil {
+		return t.file.Close()
+	}
+	return nil
+}
+
+// inode returns the platform inode number of fi, used to tell a rotated
+// file apart from the one currently open even if the path is reused.
+func inode(fi os.FileInfo) uint64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return st.Ino
+	}
+	return 0
+}
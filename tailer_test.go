@@ -0,0 +1,63 @@
+// log2life
+// by Brian C. Lane <bcl@brianlane.com>
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestTailerNextAccumulatesPartialLine confirms that a line split across
+// more than one read (the writer flushes a prefix, then finishes the line
+// after the next poll) comes back whole instead of just the suffix
+// written after the split.
+func TestTailerNextAccumulatesPartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.txt")
+	if err := os.WriteFile(path, []byte("192.168.1.1 - - [partial"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tl, err := newTailer(path, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tl.Close()
+
+	lineCh := make(chan string, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		line, err := tl.Next()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		lineCh <- line
+	}()
+
+	// Give Next a chance to hit EOF on the partial line before the rest
+	// of it is written.
+	time.Sleep(50 * time.Millisecond)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(" line] rest of request\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	select {
+	case line := <-lineCh:
+		want := "192.168.1.1 - - [partial line] rest of request"
+		if line != want {
+			t.Fatalf("Next() = %q, want %q", line, want)
+		}
+	case err := <-errCh:
+		t.Fatalf("Next() returned error: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for Next to return the completed line")
+	}
+}